@@ -0,0 +1,46 @@
+/*
+Copyright 2016 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import "time"
+
+// Backend is the exact set of CRUD verbs the session server needs from a
+// key/value store. boltbk.BoltBackend satisfies it today; etcd, Redis or
+// an in-memory store (see MemoryBackend) can satisfy it too, without
+// lib/session importing anything bolt-specific.
+type Backend interface {
+	// UpsertVal creates or overwrites key in bucket, expiring it after
+	// ttl (ttl == 0 means no expiry).
+	UpsertVal(bucket []string, key string, val []byte, ttl time.Duration) error
+	// GetVal returns the value stored at key in bucket, or a
+	// trace.NotFound error if it's missing or has expired.
+	GetVal(bucket []string, key string) ([]byte, error)
+	// GetKeys returns the keys currently present in bucket.
+	GetKeys(bucket []string) ([]string, error)
+	// DeleteKey removes key from bucket.
+	DeleteKey(bucket []string, key string) error
+}
+
+// clock is the minimal time source the session server depends on: just
+// enough to stamp and compare activity, expiry and pool bookkeeping.
+// Keeping it to one method (rather than depending on the whole
+// timetools.TimeProvider interface) means any clock double, including
+// timetools.FreezedTime and timetools.RealTime, satisfies it with no
+// adapter.
+type clock interface {
+	UtcNow() time.Time
+}
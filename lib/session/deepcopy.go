@@ -0,0 +1,62 @@
+/*
+Copyright 2016 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"encoding/json"
+
+	"github.com/gravitational/trace"
+)
+
+// DeepCopy returns a Party that shares no memory with p.
+func (p Party) DeepCopy() Party {
+	return p
+}
+
+// DeepCopy returns a Session that shares no memory with s, safe for a
+// caller to mutate freely. GetSession and GetSessions always hand out
+// deep copies so that mutating, say, the returned Parties slice can
+// never corrupt what's stored in the backend.
+func (s Session) DeepCopy() Session {
+	cp := s
+	if s.Parties != nil {
+		cp.Parties = make([]Party, len(s.Parties))
+		for i, p := range s.Parties {
+			cp.Parties[i] = p.DeepCopy()
+		}
+	}
+	return cp
+}
+
+// ToJSON serializes the session with stable field ordering (Go encodes
+// struct fields in declaration order), suitable for SessionFromJSON.
+func (s *Session) ToJSON() ([]byte, error) {
+	bytes, err := json.Marshal(s)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return bytes, nil
+}
+
+// SessionFromJSON is the inverse of Session.ToJSON.
+func SessionFromJSON(bytes []byte) (*Session, error) {
+	var sess Session
+	if err := json.Unmarshal(bytes, &sess); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &sess, nil
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2016 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+// TestSessionDeepCopy makes sure mutating a DeepCopy, or a session
+// handed back by GetSession, never reaches back into the backend's copy.
+func (s *SessionSuite) TestSessionDeepCopy(c *C) {
+	sess := Session{
+		ID:             NewID(),
+		Active:         true,
+		TerminalParams: TerminalParams{W: 100, H: 100},
+		Login:          "bob",
+		LastActive:     s.clock.UtcNow(),
+		Created:        s.clock.UtcNow(),
+		Parties: []Party{
+			{ID: NewID(), User: "bob", Role: RoleObserver},
+		},
+	}
+	c.Assert(s.srv.CreateSession(sess), IsNil)
+
+	got, err := s.srv.GetSession(sess.ID)
+	c.Assert(err, IsNil)
+
+	cp := got.DeepCopy()
+	cp.Login = "mutated"
+	cp.Parties[0].User = "mutated"
+	c.Assert(got.Login, Equals, "bob")
+	c.Assert(got.Parties[0].User, Equals, "bob")
+
+	// mutating what GetSession handed back must not reach the backend.
+	got.Login = "mutated"
+	got.Parties[0].User = "mutated"
+	again, err := s.srv.GetSession(sess.ID)
+	c.Assert(err, IsNil)
+	c.Assert(again.Login, Equals, "bob")
+	c.Assert(again.Parties[0].User, Equals, "bob")
+}
+
+// TestSessionJson makes sure a Session survives a ToJSON/SessionFromJSON
+// round trip unchanged.
+func (s *SessionSuite) TestSessionJson(c *C) {
+	sess := Session{
+		ID:             NewID(),
+		Active:         true,
+		TerminalParams: TerminalParams{W: 100, H: 100},
+		Login:          "bob",
+		LastActive:     s.clock.UtcNow(),
+		Created:        s.clock.UtcNow(),
+		Parties: []Party{
+			{ID: NewID(), User: "bob", Role: RolePeer, Permissions: DefaultPermissions(RolePeer)},
+		},
+	}
+
+	bytes, err := sess.ToJSON()
+	c.Assert(err, IsNil)
+
+	out, err := SessionFromJSON(bytes)
+	c.Assert(err, IsNil)
+	c.Assert(*out, DeepEquals, sess)
+
+	bytes2, err := out.ToJSON()
+	c.Assert(err, IsNil)
+	c.Assert(bytes2, DeepEquals, bytes)
+}
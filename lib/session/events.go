@@ -0,0 +1,202 @@
+/*
+Copyright 2016 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// eventsBucket is the bolt bucket where session event streams are stored.
+const eventsBucket = "session_events"
+
+// EventType identifies the kind of a recorded session event.
+type EventType string
+
+const (
+	// EventTypeKeystroke is raw input typed by a party.
+	EventTypeKeystroke EventType = "keystroke"
+	// EventTypeOutput is raw PTY output sent back to parties.
+	EventTypeOutput EventType = "output"
+	// EventTypeResize records a terminal resize.
+	EventTypeResize EventType = "resize"
+	// EventTypeJoin records a party joining the session.
+	EventTypeJoin EventType = "join"
+	// EventTypeLeave records a party leaving the session.
+	EventTypeLeave EventType = "leave"
+)
+
+// SessionEvent is a single timestamped entry in a session's event stream.
+type SessionEvent struct {
+	// Type is the kind of event this is.
+	Type EventType `json:"type"`
+	// Delay is the time elapsed since Session.Created when this event
+	// occurred. Event streams are ordered and replayed using Delay, not
+	// wall-clock time, so that playback speed can be adjusted.
+	Delay time.Duration `json:"delay"`
+	// Data holds the raw bytes for keystroke and output events.
+	Data []byte `json:"data,omitempty"`
+	// TerminalParams holds the new terminal size for resize events.
+	TerminalParams *TerminalParams `json:"terminal_params,omitempty"`
+	// Party holds the party that joined or left, for join/leave events.
+	Party *Party `json:"party,omitempty"`
+}
+
+// EventWriter persists and retrieves a session's event stream. The
+// backendEventWriter backs EmitSessionEvent/GetSessionEvents by default,
+// using whatever Backend the server was built with, but alternative
+// implementations (e.g. chunked S3 or filesystem storage) can be plugged
+// into New via the EventWriterOpt option.
+type EventWriter interface {
+	// WriteEvents replaces the full stream for the given session with
+	// events. It is not incremental: a caller that wants to add to the
+	// existing stream must ReadEvents first and pass the combined slice.
+	WriteEvents(id ID, events []SessionEvent) error
+	// ReadEvents returns the full recorded stream for the given session.
+	ReadEvents(id ID) ([]SessionEvent, error)
+}
+
+// backendEventWriter is the default EventWriter, storing the whole
+// stream as a single JSON blob per session in the server's Backend.
+type backendEventWriter struct {
+	bk Backend
+}
+
+// NewEventWriter returns an EventWriter backed by bk.
+func NewEventWriter(bk Backend) EventWriter {
+	return &backendEventWriter{bk: bk}
+}
+
+func (w *backendEventWriter) WriteEvents(id ID, events []SessionEvent) error {
+	bytes, err := json.Marshal(events)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return w.bk.UpsertVal([]string{eventsBucket}, string(id), bytes, 0)
+}
+
+func (w *backendEventWriter) ReadEvents(id ID) ([]SessionEvent, error) {
+	bytes, err := w.bk.GetVal([]string{eventsBucket}, string(id))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, trace.Wrap(err)
+	}
+	var events []SessionEvent
+	if err := json.Unmarshal(bytes, &events); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return events, nil
+}
+
+// EventWriterOpt sets a custom EventWriter on the server, overriding the
+// default one that stores events in the server's Backend.
+func EventWriterOpt(w EventWriter) ServiceOption {
+	return func(s *server) error {
+		s.events = w
+		return nil
+	}
+}
+
+// EmitSessionEvent timestamps ev against the session's Created time (if
+// Delay is not already set) and appends it to the session's stream.
+//
+// WriteEvents replaces the whole stored stream rather than appending to
+// it, so the read-modify-write below is locked: two concurrent emits for
+// the same session must not both read the same history and each write
+// back a copy that's missing the other's event.
+func (s *server) EmitSessionEvent(id ID, ev SessionEvent) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sess, err := s.getSession(id)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if ev.Delay == 0 {
+		ev.Delay = s.clock.UtcNow().Sub(sess.Created)
+	}
+	events, err := s.events.ReadEvents(id)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	events = append(events, ev)
+	return s.events.WriteEvents(id, events)
+}
+
+// GetSessionEvents returns the events recorded for a session. If since is
+// non-zero, only events whose Delay falls within the last `since`
+// duration of the stream are returned.
+func (s *server) GetSessionEvents(id ID, since time.Duration) ([]SessionEvent, error) {
+	events, err := s.events.ReadEvents(id)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if since == 0 || len(events) == 0 {
+		return events, nil
+	}
+	cutoff := events[len(events)-1].Delay - since
+	var out []SessionEvent
+	for _, ev := range events {
+		if ev.Delay >= cutoff {
+			out = append(out, ev)
+		}
+	}
+	return out, nil
+}
+
+// SessionPlayer replays a recorded session event stream.
+type SessionPlayer struct {
+	events []SessionEvent
+}
+
+// NewSessionPlayer returns a player for the given event stream. Events
+// are replayed in Delay order regardless of the order they're passed in.
+func NewSessionPlayer(events []SessionEvent) *SessionPlayer {
+	sorted := make([]SessionEvent, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Delay < sorted[j].Delay
+	})
+	return &SessionPlayer{events: sorted}
+}
+
+// Play writes every EventTypeOutput event's data to w, waiting between
+// events according to their recorded Delay divided by speed. speed == 1
+// replays in real time; speed == 0 replays as fast as possible.
+func (p *SessionPlayer) Play(w io.Writer, speed float64) error {
+	var prev time.Duration
+	for _, ev := range p.events {
+		wait := ev.Delay - prev
+		prev = ev.Delay
+		if speed > 0 && wait > 0 {
+			time.Sleep(time.Duration(float64(wait) / speed))
+		}
+		if ev.Type != EventTypeOutput {
+			continue
+		}
+		if _, err := w.Write(ev.Data); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
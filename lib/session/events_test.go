@@ -0,0 +1,107 @@
+/*
+Copyright 2016 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// TestSessionEvents writes a scripted event stream and asserts that
+// GetSessionEvents and SessionPlayer reproduce it in deterministic order.
+func (s *SessionSuite) TestSessionEvents(c *C) {
+	sess := Session{
+		ID:             NewID(),
+		Active:         true,
+		TerminalParams: TerminalParams{W: 80, H: 24},
+		Login:          "bob",
+		LastActive:     s.clock.UtcNow(),
+		Created:        s.clock.UtcNow(),
+	}
+	c.Assert(s.srv.CreateSession(sess), IsNil)
+
+	// emit events out of order; the player must still replay by Delay.
+	c.Assert(s.srv.EmitSessionEvent(sess.ID, SessionEvent{
+		Type:  EventTypeOutput,
+		Delay: 2 * time.Second,
+		Data:  []byte("world"),
+	}), IsNil)
+	c.Assert(s.srv.EmitSessionEvent(sess.ID, SessionEvent{
+		Type:  EventTypeOutput,
+		Delay: 1 * time.Second,
+		Data:  []byte("hello "),
+	}), IsNil)
+	c.Assert(s.srv.EmitSessionEvent(sess.ID, SessionEvent{
+		Type:  EventTypeResize,
+		Delay: 3 * time.Second,
+		TerminalParams: &TerminalParams{
+			W: 100, H: 40,
+		},
+	}), IsNil)
+
+	events, err := s.srv.GetSessionEvents(sess.ID, 0)
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 3)
+
+	// only the last second of the stream.
+	recent, err := s.srv.GetSessionEvents(sess.ID, time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(recent, HasLen, 2)
+
+	var out bytes.Buffer
+	player := NewSessionPlayer(events)
+	c.Assert(player.Play(&out, 0), IsNil)
+	c.Assert(out.String(), Equals, "hello world")
+}
+
+// TestEmitSessionEventRace makes sure concurrent EmitSessionEvent calls
+// for the same session each see the others' appends, rather than racing
+// the read-modify-write and silently dropping one under WriteEvents'
+// replace-the-whole-stream semantics.
+func (s *SessionSuite) TestEmitSessionEventRace(c *C) {
+	sess := Session{
+		ID:             NewID(),
+		Active:         true,
+		TerminalParams: TerminalParams{W: 80, H: 24},
+		Login:          "bob",
+		LastActive:     s.clock.UtcNow(),
+		Created:        s.clock.UtcNow(),
+	}
+	c.Assert(s.srv.CreateSession(sess), IsNil)
+
+	const n = 10
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.srv.EmitSessionEvent(sess.ID, SessionEvent{
+				Type:  EventTypeKeystroke,
+				Delay: time.Duration(i) * time.Millisecond,
+				Data:  []byte{byte(i)},
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	events, err := s.srv.GetSessionEvents(sess.ID, 0)
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, n)
+}
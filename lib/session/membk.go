@@ -0,0 +1,110 @@
+/*
+Copyright 2016 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// MemoryBackend is a Backend that keeps everything in memory. It exists
+// so the session test matrix can run without touching disk; real
+// deployments use boltbk, etcd, or similar.
+type MemoryBackend struct {
+	mu    sync.Mutex
+	clock clock
+	data  map[string]map[string]memVal
+}
+
+// memVal is a stored value together with its expiry, if any.
+type memVal struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+func (v memVal) expired(now time.Time) bool {
+	return !v.expiresAt.IsZero() && !now.Before(v.expiresAt)
+}
+
+// NewMemoryBackend returns an empty MemoryBackend that uses c to decide
+// when TTLs have elapsed.
+func NewMemoryBackend(c clock) *MemoryBackend {
+	return &MemoryBackend{
+		clock: c,
+		data:  make(map[string]map[string]memVal),
+	}
+}
+
+func bucketKey(bucket []string) string {
+	return strings.Join(bucket, "\x00")
+}
+
+// UpsertVal creates or overwrites key in bucket.
+func (m *MemoryBackend) UpsertVal(bucket []string, key string, val []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bk := bucketKey(bucket)
+	if m.data[bk] == nil {
+		m.data[bk] = make(map[string]memVal)
+	}
+	var expiresAt time.Time
+	if ttl != 0 {
+		expiresAt = m.clock.UtcNow().Add(ttl)
+	}
+	m.data[bk][key] = memVal{data: append([]byte(nil), val...), expiresAt: expiresAt}
+	return nil
+}
+
+// GetVal returns the value stored at key in bucket.
+func (m *MemoryBackend) GetVal(bucket []string, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.data[bucketKey(bucket)][key]
+	if !ok || v.expired(m.clock.UtcNow()) {
+		return nil, trace.NotFound("key %q is not found in %v", key, bucket)
+	}
+	return append([]byte(nil), v.data...), nil
+}
+
+// GetKeys returns the keys currently present (and unexpired) in bucket.
+func (m *MemoryBackend) GetKeys(bucket []string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.clock.UtcNow()
+	var out []string
+	for key, v := range m.data[bucketKey(bucket)] {
+		if !v.expired(now) {
+			out = append(out, key)
+		}
+	}
+	return out, nil
+}
+
+// DeleteKey removes key from bucket.
+func (m *MemoryBackend) DeleteKey(bucket []string, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bk := bucketKey(bucket)
+	if _, ok := m.data[bk][key]; !ok {
+		return trace.NotFound("key %q is not found in %v", key, bucket)
+	}
+	delete(m.data[bk], key)
+	return nil
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2016 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// TestMemoryBackendTTL makes sure MemoryBackend honors UpsertVal's ttl,
+// the same contract boltbk.BoltBackend provides.
+func (s *MemorySuite) TestMemoryBackendTTL(c *C) {
+	bk := NewMemoryBackend(s.clock)
+	c.Assert(bk.UpsertVal([]string{"b"}, "k", []byte("v"), time.Second), IsNil)
+
+	val, err := bk.GetVal([]string{"b"}, "k")
+	c.Assert(err, IsNil)
+	c.Assert(string(val), Equals, "v")
+
+	s.clock.Sleep(2 * time.Second)
+
+	_, err = bk.GetVal([]string{"b"}, "k")
+	c.Assert(err, NotNil)
+
+	keys, err := bk.GetKeys([]string{"b"})
+	c.Assert(err, IsNil)
+	c.Assert(keys, HasLen, 0)
+}
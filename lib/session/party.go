@@ -0,0 +1,130 @@
+/*
+Copyright 2016 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"github.com/gravitational/trace"
+)
+
+// PartyPermissions is a bitmask of the actions a party is allowed to take
+// within a session.
+type PartyPermissions uint32
+
+const (
+	// PermissionRead lets a party view terminal output.
+	PermissionRead PartyPermissions = 1 << iota
+	// PermissionWrite lets a party send keystrokes.
+	PermissionWrite
+	// PermissionResize lets a party change the terminal size.
+	PermissionResize
+	// PermissionInvite lets a party add new parties to the session.
+	PermissionInvite
+	// PermissionKick lets a party remove other parties from the session.
+	PermissionKick
+)
+
+// Has reports whether p includes the given permission.
+func (p PartyPermissions) Has(perm PartyPermissions) bool {
+	return p&perm == perm
+}
+
+// PartyRole is a human-readable label for a party's typical permission
+// set. It's informational; AuthorizePartyAction only ever consults
+// Party.Permissions.
+type PartyRole string
+
+const (
+	// RoleObserver is a read-only party.
+	RoleObserver PartyRole = "observer"
+	// RolePeer can read, write and resize, but not manage other parties.
+	RolePeer PartyRole = "peer"
+	// RoleModerator has every permission, including Invite and Kick.
+	RoleModerator PartyRole = "moderator"
+)
+
+// DefaultPermissions returns the conventional permission set for role.
+// Unrecognized roles get the observer (read-only) permission set.
+func DefaultPermissions(role PartyRole) PartyPermissions {
+	switch role {
+	case RolePeer:
+		return PermissionRead | PermissionWrite | PermissionResize
+	case RoleModerator:
+		return PermissionRead | PermissionWrite | PermissionResize | PermissionInvite | PermissionKick
+	default:
+		return PermissionRead
+	}
+}
+
+// PartyAction is an action a party may attempt within a session, subject
+// to authorization via AuthorizePartyAction.
+type PartyAction string
+
+const (
+	// ActionWrite is sending keystrokes.
+	ActionWrite PartyAction = "write"
+	// ActionResize is changing the terminal size.
+	ActionResize PartyAction = "resize"
+	// ActionInvite is adding a new party to the session.
+	ActionInvite PartyAction = "invite"
+	// ActionKick is removing another party from the session.
+	ActionKick PartyAction = "kick"
+)
+
+// permissionFor maps a PartyAction to the PartyPermissions bit that
+// authorizes it. The second return value is false for an action this
+// function doesn't recognize, so callers can reject it outright instead
+// of silently falling back to a permission bit (0 is satisfied by
+// Has() regardless of p, which would authorize an unrecognized action
+// for every party, including ones with no permissions at all).
+func permissionFor(action PartyAction) (PartyPermissions, bool) {
+	switch action {
+	case ActionWrite:
+		return PermissionWrite, true
+	case ActionResize:
+		return PermissionResize, true
+	case ActionInvite:
+		return PermissionInvite, true
+	case ActionKick:
+		return PermissionKick, true
+	default:
+		return 0, false
+	}
+}
+
+// AuthorizePartyAction checks whether the party identified by partyID is
+// permitted to perform action within the given session, returning
+// trace.AccessDenied if not.
+func (s *server) AuthorizePartyAction(sessionID ID, partyID ID, action PartyAction) error {
+	perm, ok := permissionFor(action)
+	if !ok {
+		return trace.BadParameter("unknown party action %q", action)
+	}
+	sess, err := s.getSession(sessionID)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, p := range sess.Parties {
+		if p.ID != partyID {
+			continue
+		}
+		if !p.Permissions.Has(perm) {
+			return trace.AccessDenied("party %v is not permitted to %v in session %v", partyID, action, sessionID)
+		}
+		return nil
+	}
+	return trace.NotFound("party %v is not in session %v", partyID, sessionID)
+}
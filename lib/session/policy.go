@@ -0,0 +1,130 @@
+/*
+Copyright 2016 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"time"
+
+	"github.com/gravitational/teleport/lib/defaults"
+)
+
+// SessionPolicy controls when a session is considered expired. The zero
+// value preserves the legacy behavior: idle out after
+// defaults.ActiveSessionTTL, never expire by age alone.
+type SessionPolicy struct {
+	// IdleTimeout is how long a session can go without activity (an
+	// UpdateSession call that sets LastActive) before it expires. Zero
+	// means defaults.ActiveSessionTTL.
+	IdleTimeout time.Duration `json:"idle_timeout"`
+	// MaxAge is the absolute lifetime of a session, measured from
+	// Created and never extended by activity. Zero means unbounded.
+	MaxAge time.Duration `json:"max_age"`
+	// WarnBefore, if non-zero, causes an EventTypeExpiryWarning event to
+	// be emitted this long before the session is due to expire.
+	WarnBefore time.Duration `json:"warn_before"`
+}
+
+// ExpiryReason explains why a session was expired.
+type ExpiryReason string
+
+const (
+	// ExpiredIdle means the session was idle for longer than its
+	// IdleTimeout.
+	ExpiredIdle ExpiryReason = "idle"
+	// ExpiredMaxAge means the session reached its absolute MaxAge.
+	ExpiredMaxAge ExpiryReason = "max_age"
+)
+
+const (
+	// EventTypeExpired records that a session was expired, with the
+	// ExpiryReason in Data.
+	EventTypeExpired EventType = "expired"
+	// EventTypeExpiryWarning records that a session is about to expire,
+	// with the ExpiryReason it's about to expire for in Data.
+	EventTypeExpiryWarning EventType = "expiry_warning"
+)
+
+// idleTimeout returns the session's effective idle timeout.
+func (p SessionPolicy) idleTimeout() time.Duration {
+	if p.IdleTimeout == 0 {
+		return defaults.ActiveSessionTTL
+	}
+	return p.IdleTimeout
+}
+
+// checkExpiry reports whether sess has expired as of now, and why.
+// MaxAge is checked first, since it can never be postponed by activity.
+func checkExpiry(sess *Session, now time.Time) (bool, ExpiryReason) {
+	if sess.Policy.MaxAge != 0 && now.Sub(sess.Created) >= sess.Policy.MaxAge {
+		return true, ExpiredMaxAge
+	}
+	if now.Sub(sess.LastActive) >= sess.Policy.idleTimeout() {
+		return true, ExpiredIdle
+	}
+	return false, ""
+}
+
+// expireSession emits an EventTypeExpired event and removes sess from the
+// backend. Errors are best-effort: an expiring session should disappear
+// from GetSession regardless of whether the bookkeeping below succeeds.
+func (s *server) expireSession(sess *Session, reason ExpiryReason) {
+	s.events.WriteEvents(sess.ID, append(mustReadEvents(s, sess.ID), SessionEvent{
+		Type:  EventTypeExpired,
+		Delay: s.clock.UtcNow().Sub(sess.Created),
+		Data:  []byte(reason),
+	}))
+	s.bk.DeleteKey([]string{sessionsBucket}, string(sess.ID))
+}
+
+// maybeWarn emits an EventTypeExpiryWarning event if sess is within its
+// WarnBefore window and hasn't already been warned this activity period.
+// It reports whether sess.Warned was flipped, so the caller knows to
+// persist the change.
+func (s *server) maybeWarn(sess *Session, now time.Time) bool {
+	if sess.Policy.WarnBefore == 0 || sess.Warned {
+		return false
+	}
+	idleRemaining := sess.Policy.idleTimeout() - now.Sub(sess.LastActive)
+	reason := ExpiredIdle
+	remaining := idleRemaining
+	if sess.Policy.MaxAge != 0 {
+		if ageRemaining := sess.Policy.MaxAge - now.Sub(sess.Created); ageRemaining < remaining {
+			remaining, reason = ageRemaining, ExpiredMaxAge
+		}
+	}
+	if remaining > sess.Policy.WarnBefore {
+		return false
+	}
+	s.events.WriteEvents(sess.ID, append(mustReadEvents(s, sess.ID), SessionEvent{
+		Type:  EventTypeExpiryWarning,
+		Delay: now.Sub(sess.Created),
+		Data:  []byte(reason),
+	}))
+	sess.Warned = true
+	return true
+}
+
+// mustReadEvents reads the existing event stream for id, treating any
+// error as an empty stream; used from expiry bookkeeping where we'd
+// rather drop an event than fail GetSession.
+func mustReadEvents(s *server, id ID) []SessionEvent {
+	events, err := s.events.ReadEvents(id)
+	if err != nil {
+		return nil
+	}
+	return events
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2016 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// TestSessionIdleTimeout makes sure a custom IdleTimeout is honored, and
+// that activity reported through UpdateSession resets it.
+func (s *SessionSuite) TestSessionIdleTimeout(c *C) {
+	sess := Session{
+		ID:             NewID(),
+		Active:         true,
+		TerminalParams: TerminalParams{W: 100, H: 100},
+		Login:          "bob",
+		LastActive:     s.clock.UtcNow(),
+		Created:        s.clock.UtcNow(),
+		Policy:         SessionPolicy{IdleTimeout: 5 * time.Second},
+	}
+	c.Assert(s.srv.CreateSession(sess), IsNil)
+
+	s.clock.Sleep(3 * time.Second)
+
+	// report activity, which should reset the idle clock.
+	now := s.clock.UtcNow()
+	c.Assert(s.srv.UpdateSession(UpdateRequest{ID: sess.ID, LastActive: &now}), IsNil)
+
+	s.clock.Sleep(3 * time.Second)
+	s2, err := s.srv.GetSession(sess.ID)
+	c.Assert(err, IsNil)
+	c.Assert(s2, NotNil)
+
+	s.clock.Sleep(3 * time.Second)
+	s2, reason, err := s.srv.GetSessionWithReason(sess.ID)
+	c.Assert(err, IsNil)
+	c.Assert(s2, IsNil)
+	c.Assert(reason, Equals, ExpiredIdle)
+
+	events, err := s.srv.GetSessionEvents(sess.ID, 0)
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 1)
+	c.Assert(events[0].Type, Equals, EventTypeExpired)
+	c.Assert(ExpiryReason(events[0].Data), Equals, ExpiredIdle)
+}
+
+// TestSessionMaxAge makes sure a session is expired once it reaches its
+// MaxAge, even if it has seen recent activity.
+func (s *SessionSuite) TestSessionMaxAge(c *C) {
+	sess := Session{
+		ID:             NewID(),
+		Active:         true,
+		TerminalParams: TerminalParams{W: 100, H: 100},
+		Login:          "bob",
+		LastActive:     s.clock.UtcNow(),
+		Created:        s.clock.UtcNow(),
+		Policy:         SessionPolicy{IdleTimeout: time.Hour, MaxAge: 5 * time.Second},
+	}
+	c.Assert(s.srv.CreateSession(sess), IsNil)
+
+	s.clock.Sleep(3 * time.Second)
+	now := s.clock.UtcNow()
+	c.Assert(s.srv.UpdateSession(UpdateRequest{ID: sess.ID, LastActive: &now}), IsNil)
+
+	s.clock.Sleep(3 * time.Second)
+	s2, reason, err := s.srv.GetSessionWithReason(sess.ID)
+	c.Assert(err, IsNil)
+	c.Assert(s2, IsNil)
+	c.Assert(reason, Equals, ExpiredMaxAge)
+
+	events, err := s.srv.GetSessionEvents(sess.ID, 0)
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 1)
+	c.Assert(ExpiryReason(events[0].Data), Equals, ExpiredMaxAge)
+}
+
+// TestSessionExpiryWarning makes sure a warning event is emitted once,
+// WarnBefore the session's idle timeout.
+func (s *SessionSuite) TestSessionExpiryWarning(c *C) {
+	sess := Session{
+		ID:             NewID(),
+		Active:         true,
+		TerminalParams: TerminalParams{W: 100, H: 100},
+		Login:          "bob",
+		LastActive:     s.clock.UtcNow(),
+		Created:        s.clock.UtcNow(),
+		Policy:         SessionPolicy{IdleTimeout: 5 * time.Second, WarnBefore: 2 * time.Second},
+	}
+	c.Assert(s.srv.CreateSession(sess), IsNil)
+
+	s.clock.Sleep(4 * time.Second)
+	_, err := s.srv.GetSession(sess.ID)
+	c.Assert(err, IsNil)
+
+	// a second read within the same warning window must not duplicate it.
+	_, err = s.srv.GetSession(sess.ID)
+	c.Assert(err, IsNil)
+
+	events, err := s.srv.GetSessionEvents(sess.ID, 0)
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 1)
+	c.Assert(events[0].Type, Equals, EventTypeExpiryWarning)
+	c.Assert(ExpiryReason(events[0].Data), Equals, ExpiredIdle)
+}
+
+// TestSessionExpiryRace makes sure concurrent GetSession calls racing
+// past an already-expired session only append a single EventTypeExpired
+// entry, not one per racing caller.
+func (s *SessionSuite) TestSessionExpiryRace(c *C) {
+	sess := Session{
+		ID:             NewID(),
+		Active:         true,
+		TerminalParams: TerminalParams{W: 100, H: 100},
+		Login:          "bob",
+		LastActive:     s.clock.UtcNow(),
+		Created:        s.clock.UtcNow(),
+		Policy:         SessionPolicy{IdleTimeout: 5 * time.Second},
+	}
+	c.Assert(s.srv.CreateSession(sess), IsNil)
+	s.clock.Sleep(6 * time.Second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.srv.GetSession(sess.ID)
+		}()
+	}
+	wg.Wait()
+
+	events, err := s.srv.GetSessionEvents(sess.ID, 0)
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 1)
+	c.Assert(events[0].Type, Equals, EventTypeExpired)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2016 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultPoolTimeout is how long a released session ID stays eligible for
+// reuse before it's treated as stale and dropped on checkout.
+const defaultPoolTimeout = time.Minute
+
+// Pool vends session IDs from a LIFO free-list of recently released ones,
+// falling back to a fresh UUID when the list is empty or every entry on
+// it has gone stale. Reusing the most-recently-freed ID lets short-lived
+// reconnect flows (e.g. an SSH client drop and resume) correlate audit
+// records across the drop, the same way a connection pool hands back the
+// most-recently-used connection first.
+type Pool struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	clock   clock
+	free    []pooledID
+}
+
+// pooledID is a session ID sitting on the free list, along with when it
+// was released.
+type pooledID struct {
+	id      ID
+	freedAt time.Time
+}
+
+// NewPool returns a Pool whose released IDs are eligible for reuse for
+// timeout after being released.
+func NewPool(timeout time.Duration, c clock) *Pool {
+	if timeout == 0 {
+		timeout = defaultPoolTimeout
+	}
+	return &Pool{timeout: timeout, clock: c}
+}
+
+// Release pushes id onto the top of the free list, making it the next ID
+// handed out by Checkout.
+func (p *Pool) Release(id ID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.free = append(p.free, pooledID{id: id, freedAt: p.clock.UtcNow()})
+}
+
+// Checkout pops the most-recently-released, still-fresh ID off the free
+// list. Stale entries encountered along the way are dropped. It reports
+// false if no fresh ID is available.
+func (p *Pool) Checkout() (ID, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.clock.UtcNow()
+	for len(p.free) > 0 {
+		last := len(p.free) - 1
+		entry := p.free[last]
+		p.free = p.free[:last]
+		if now.Sub(entry.freedAt) > p.timeout {
+			continue
+		}
+		return entry.id, true
+	}
+	return "", false
+}
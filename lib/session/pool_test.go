@@ -0,0 +1,107 @@
+/*
+Copyright 2016 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// TestLifo makes sure the most-recently-released session ID is the next
+// one handed out, across a boltbk-backed server.
+func (s *BoltSuite) TestLifo(c *C) {
+	first := s.srv.NextSessionID()
+	second := s.srv.NextSessionID()
+	c.Assert(first, Not(Equals), second)
+
+	s.srv.ReleaseSession(first)
+	s.srv.ReleaseSession(second)
+
+	// second was released last, so it comes back first.
+	c.Assert(s.srv.NextSessionID(), Equals, second)
+	c.Assert(s.srv.NextSessionID(), Equals, first)
+
+	// pool is empty again: a fresh ID is minted.
+	c.Assert(s.srv.NextSessionID(), Not(Equals), first)
+}
+
+// TestExpiredRemoved makes sure a released ID that's gone stale is
+// dropped on checkout rather than handed back out.
+func (s *BoltSuite) TestExpiredRemoved(c *C) {
+	srv, err := New(s.bk, Clock(s.clock), PoolTimeout(time.Minute))
+	c.Assert(err, IsNil)
+
+	stale := srv.NextSessionID()
+	srv.ReleaseSession(stale)
+
+	s.clock.Sleep(time.Minute + time.Second)
+
+	fresh := srv.NextSessionID()
+	c.Assert(fresh, Not(Equals), stale)
+
+	// the stale entry was dropped, not merely skipped: releasing a new
+	// ID and checking out twice must not resurrect it.
+	srv.ReleaseSession(fresh)
+	c.Assert(srv.NextSessionID(), Equals, fresh)
+	c.Assert(srv.NextSessionID(), Not(Equals), stale)
+}
+
+// TestCreateSessionRequiresID makes sure CreateSession rejects a session
+// with an unset or malformed ID rather than silently minting one, since
+// it has no way to hand a generated ID back to the caller.
+func (s *BoltSuite) TestCreateSessionRequiresID(c *C) {
+	sess := Session{
+		Active:         true,
+		TerminalParams: TerminalParams{W: 80, H: 24},
+		Login:          "bob",
+		LastActive:     s.clock.UtcNow(),
+		Created:        s.clock.UtcNow(),
+	}
+	c.Assert(s.srv.CreateSession(sess), NotNil)
+
+	sess.ID = ID("garbage")
+	c.Assert(s.srv.CreateSession(sess), NotNil)
+}
+
+// TestCreateSessionReusesReleasedID makes sure a caller that builds
+// sess.ID from NextSessionID, as CreateSession's doc comment prescribes
+// for reconnects, gets a session stored under the reused ID.
+func (s *BoltSuite) TestCreateSessionReusesReleasedID(c *C) {
+	first := Session{
+		ID:             s.srv.NextSessionID(),
+		Active:         true,
+		TerminalParams: TerminalParams{W: 80, H: 24},
+		Login:          "bob",
+		LastActive:     s.clock.UtcNow(),
+		Created:        s.clock.UtcNow(),
+	}
+	c.Assert(s.srv.CreateSession(first), IsNil)
+
+	s.srv.ReleaseSession(first.ID)
+
+	second := Session{
+		ID:             s.srv.NextSessionID(),
+		Active:         true,
+		TerminalParams: TerminalParams{W: 80, H: 24},
+		Login:          "bob",
+		LastActive:     s.clock.UtcNow(),
+		Created:        s.clock.UtcNow(),
+	}
+	c.Assert(second.ID, Equals, first.ID)
+	c.Assert(s.srv.CreateSession(second), IsNil)
+}
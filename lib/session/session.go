@@ -0,0 +1,376 @@
+/*
+Copyright 2015-2016 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package session is used for tracking interactive sessions that happen
+// in a Teleport cluster. Each session has one or more "parties", clients
+// that are attached to the same terminal.
+package session
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gravitational/trace"
+	"github.com/mailgun/timetools"
+	"github.com/pborman/uuid"
+)
+
+// sessionsBucket is the bolt bucket where session records are stored.
+const sessionsBucket = "sessions"
+
+// ID is a unique session identifier, a UUIDv4 string.
+type ID string
+
+// NewID returns a new session ID.
+func NewID() ID {
+	return ID(uuid.New())
+}
+
+// String returns string representation of the ID.
+func (id ID) String() string {
+	return string(id)
+}
+
+// Check validates that the ID is well formed.
+func (id ID) Check() error {
+	_, err := ParseID(string(id))
+	return trace.Wrap(err)
+}
+
+// ParseID parses a string and returns a session ID if it's valid.
+func ParseID(id string) (*ID, error) {
+	if uuid.Parse(id) == nil {
+		return nil, trace.BadParameter("'%v' is not a valid session ID", id)
+	}
+	sid := ID(id)
+	return &sid, nil
+}
+
+// TerminalParams holds the terminal size of a session.
+type TerminalParams struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// Party is a participant of a session, e.g. the originating user or
+// someone who has joined an existing session to observe or collaborate.
+type Party struct {
+	// ID is a unique party id.
+	ID ID `json:"id"`
+	// RemoteAddr is the address of the party's connection.
+	RemoteAddr string `json:"remote_addr"`
+	// User is the teleport user that owns this party.
+	User string `json:"user"`
+	// ServerID is the ID of the server this party is connected to.
+	ServerID string `json:"server_id"`
+	// LastActive is the last time this party was active.
+	LastActive time.Time `json:"last_active"`
+	// Role is a human-readable label for this party's place in the
+	// session, e.g. "observer", "peer" or "moderator".
+	Role PartyRole `json:"role"`
+	// Permissions is the bitmask of actions this party is authorized to
+	// perform, enforced by AuthorizePartyAction.
+	Permissions PartyPermissions `json:"permissions"`
+}
+
+// Session is a record of an interactive session, its terminal and the
+// parties that have joined it.
+type Session struct {
+	// ID is a unique session identifier.
+	ID ID `json:"id"`
+	// Active indicates whether the session is currently live.
+	Active bool `json:"active"`
+	// TerminalParams sets the initial size of the terminal.
+	TerminalParams TerminalParams `json:"terminal_params"`
+	// Login is the OS login of the session.
+	Login string `json:"login"`
+	// Created records when the session was created.
+	Created time.Time `json:"created"`
+	// LastActive records the last time the session saw any activity.
+	LastActive time.Time `json:"last_active"`
+	// Parties is the list of people currently attached to the session.
+	Parties []Party `json:"parties"`
+	// Policy controls when the session is expired. The zero value keeps
+	// the legacy behavior of a single defaults.ActiveSessionTTL idle
+	// timeout and no maximum age.
+	Policy SessionPolicy `json:"policy"`
+	// Warned is set once an expiry warning event has been emitted for
+	// the session's current activity period, so it's not emitted twice.
+	Warned bool `json:"warned"`
+}
+
+// RemoveParty removes a party with the given ID from the session,
+// returning true if a party was actually removed.
+func (s *Session) RemoveParty(id ID) bool {
+	for i, p := range s.Parties {
+		if p.ID == id {
+			s.Parties = append(s.Parties[:i], s.Parties[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateRequest is a request to update fields of an existing session.
+// Only non-nil fields are applied.
+type UpdateRequest struct {
+	ID             ID              `json:"id"`
+	Active         *bool           `json:"active,omitempty"`
+	TerminalParams *TerminalParams `json:"terminal_params,omitempty"`
+	Parties        *[]Party        `json:"parties,omitempty"`
+	// LastActive, when set, marks the session as active at the given
+	// time, resetting its idle timeout and pending expiry warning.
+	LastActive *time.Time `json:"last_active,omitempty"`
+}
+
+// Check validates the update request.
+func (u *UpdateRequest) Check() error {
+	if err := u.ID.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+// Bool returns a pointer to the given bool, for use with UpdateRequest's
+// optional fields.
+func Bool(b bool) *bool {
+	return &b
+}
+
+// Service manages the lifecycle of interactive sessions.
+type Service interface {
+	// CreateSession creates a new session.
+	CreateSession(sess Session) error
+	// GetSessions returns all active sessions.
+	GetSessions() ([]Session, error)
+	// GetSession returns a session by ID, or nil if it has just expired.
+	// A session that does not exist yields a trace.NotFound error.
+	GetSession(id ID) (*Session, error)
+	// GetSessionWithReason behaves like GetSession, but also reports why
+	// the session was just expired (if it was), so a caller can render
+	// an accurate disconnect message without a second call to
+	// GetSessionEvents.
+	GetSessionWithReason(id ID) (*Session, ExpiryReason, error)
+	// UpdateSession updates fields of an existing session.
+	UpdateSession(req UpdateRequest) error
+	// EmitSessionEvent appends an event to a session's event stream.
+	EmitSessionEvent(id ID, ev SessionEvent) error
+	// GetSessionEvents returns events recorded for a session, optionally
+	// only those that happened in the last `since` duration.
+	GetSessionEvents(id ID, since time.Duration) ([]SessionEvent, error)
+	// AuthorizePartyAction checks whether a party is permitted to
+	// perform action within a session.
+	AuthorizePartyAction(sessionID ID, partyID ID, action PartyAction) error
+	// NextSessionID returns the most-recently-released session ID still
+	// eligible for reuse, or a fresh one if the pool is empty or stale.
+	// Callers that want reconnects to reuse an ID should call this
+	// before CreateSession rather than NewID.
+	NextSessionID() ID
+	// ReleaseSession returns id to the pool for reuse by a future
+	// NextSessionID call.
+	ReleaseSession(id ID)
+}
+
+// ServiceOption configures a server created by New.
+type ServiceOption func(s *server) error
+
+// Clock sets the time source used by the session service. Any
+// timetools.TimeProvider (including timetools.FreezedTime in tests)
+// works here, since it's a superset of the clock interface server needs.
+func Clock(c clock) ServiceOption {
+	return func(s *server) error {
+		s.clock = c
+		return nil
+	}
+}
+
+// PoolTimeout sets how long a released session ID stays eligible for
+// reuse. It defaults to defaultPoolTimeout.
+func PoolTimeout(timeout time.Duration) ServiceOption {
+	return func(s *server) error {
+		s.poolTimeout = timeout
+		return nil
+	}
+}
+
+// server is the Backend-agnostic implementation of Service. It used to
+// be hard-wired to *boltbk.BoltBackend; now it depends only on the
+// Backend interface, so an in-memory store (see MemoryBackend) or any
+// other future store can be dropped in without touching this file.
+type server struct {
+	bk          Backend
+	clock       clock
+	events      EventWriter
+	pool        *Pool
+	poolTimeout time.Duration
+	lock        sync.Mutex
+}
+
+// New returns a new session Service backed by bk.
+func New(bk Backend, opts ...ServiceOption) (Service, error) {
+	s := &server{
+		bk:    bk,
+		clock: &timetools.RealTime{},
+	}
+	s.events = NewEventWriter(bk)
+	for _, o := range opts {
+		if err := o(s); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	s.pool = NewPool(s.poolTimeout, s.clock)
+	return s, nil
+}
+
+// CreateSession creates a new session record. sess.ID must already be a
+// valid, set ID: CreateSession never mints or pool-checks one itself,
+// since its signature (matching the rest of this package's CRUD verbs)
+// has no way to hand a generated ID back to the caller. Build sess.ID
+// with NewID() for a plain new session, or with NextSessionID() instead
+// when sess represents a reconnect and the caller wants to reuse a
+// recently-released ID from the pool (see ReleaseSession).
+func (s *server) CreateSession(sess Session) error {
+	if err := sess.ID.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	if sess.Created.IsZero() || sess.LastActive.IsZero() {
+		return trace.BadParameter("session %v: Created and LastActive must be set", sess.ID)
+	}
+	return s.upsertSession(&sess)
+}
+
+// NextSessionID returns the most-recently-released session ID still
+// eligible for reuse, or a fresh one if none is available.
+func (s *server) NextSessionID() ID {
+	if id, ok := s.pool.Checkout(); ok {
+		return id
+	}
+	return NewID()
+}
+
+// ReleaseSession returns id to the pool so NextSessionID can hand it back
+// out to a reconnecting client.
+func (s *server) ReleaseSession(id ID) {
+	s.pool.Release(id)
+}
+
+// GetSessions returns all sessions that have not expired.
+func (s *server) GetSessions() ([]Session, error) {
+	keys, err := s.bk.GetKeys([]string{sessionsBucket})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var out []Session
+	for _, key := range keys {
+		sess, err := s.GetSession(ID(key))
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if sess != nil {
+			out = append(out, *sess)
+		}
+	}
+	return out, nil
+}
+
+// GetSession returns the session with the given ID. If the session has
+// been inactive for longer than its policy's idle timeout (or has
+// reached its MaxAge) it is removed and nil is returned instead of an
+// error. Use GetSessionWithReason to learn why it was expired.
+func (s *server) GetSession(id ID) (*Session, error) {
+	sess, _, err := s.GetSessionWithReason(id)
+	return sess, err
+}
+
+// GetSessionWithReason is GetSession plus the ExpiryReason the session
+// was just expired for, if it was. The reason is the zero value when
+// sess is non-nil.
+//
+// The read-check-expire-persist sequence below is locked so two
+// concurrent callers racing past checkExpiry/maybeWarn can't both
+// append an expiry or warning event for the same transition.
+func (s *server) GetSessionWithReason(id ID) (*Session, ExpiryReason, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sess, err := s.getSession(id)
+	if err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+	now := s.clock.UtcNow()
+	if expired, reason := checkExpiry(sess, now); expired {
+		s.expireSession(sess, reason)
+		return nil, reason, nil
+	}
+	if s.maybeWarn(sess, now) {
+		if err := s.upsertSession(sess); err != nil {
+			return nil, "", trace.Wrap(err)
+		}
+	}
+	cp := sess.DeepCopy()
+	return &cp, "", nil
+}
+
+// UpdateSession applies the non-nil fields of req to the stored session.
+func (s *server) UpdateSession(req UpdateRequest) error {
+	if err := req.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sess, err := s.getSession(req.ID)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if req.Active != nil {
+		sess.Active = *req.Active
+	}
+	if req.TerminalParams != nil {
+		sess.TerminalParams = *req.TerminalParams
+	}
+	if req.Parties != nil {
+		sess.Parties = *req.Parties
+	}
+	if req.LastActive != nil {
+		sess.LastActive = *req.LastActive
+		sess.Warned = false
+	}
+	return s.upsertSession(sess)
+}
+
+// getSession reads a session record straight from the backend, without
+// applying the inactivity TTL.
+func (s *server) getSession(id ID) (*Session, error) {
+	bytes, err := s.bk.GetVal([]string{sessionsBucket}, string(id))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var sess Session
+	if err := json.Unmarshal(bytes, &sess); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &sess, nil
+}
+
+func (s *server) upsertSession(sess *Session) error {
+	bytes, err := json.Marshal(sess)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return s.bk.UpsertVal([]string{sessionsBucket}, string(sess.ID), bytes, 0)
+}
@@ -31,13 +31,24 @@ import (
 
 func TestSessions(t *testing.T) { TestingT(t) }
 
-type BoltSuite struct {
-	dir   string
+// SessionSuite holds the test matrix shared by every Backend
+// implementation. BoltSuite and MemorySuite each embed it and provide
+// their own SetUpTest to wire in a different Backend, so the whole
+// matrix runs once per Backend without duplicating a single test.
+type SessionSuite struct {
 	srv   *server
-	bk    *boltbk.BoltBackend
 	clock *timetools.FreezedTime
 }
 
+// BoltSuite runs the session test matrix against the real boltbk
+// backend. It's the integration suite: it touches disk and exercises
+// the on-disk format, unlike MemorySuite.
+type BoltSuite struct {
+	SessionSuite
+	dir string
+	bk  *boltbk.BoltBackend
+}
+
 var _ = Suite(&BoltSuite{})
 
 func (s *BoltSuite) SetUpSuite(c *C) {
@@ -63,7 +74,28 @@ func (s *BoltSuite) TearDownTest(c *C) {
 	c.Assert(s.bk.Close(), IsNil)
 }
 
-func (s *BoltSuite) TestID(c *C) {
+// MemorySuite runs the same session test matrix against MemoryBackend,
+// so it exercises lib/session's logic without ever touching disk.
+type MemorySuite struct {
+	SessionSuite
+}
+
+var _ = Suite(&MemorySuite{})
+
+func (s *MemorySuite) SetUpSuite(c *C) {
+	utils.InitLoggerForTests()
+}
+
+func (s *MemorySuite) SetUpTest(c *C) {
+	s.clock = &timetools.FreezedTime{
+		CurrentTime: time.Date(2016, 9, 8, 7, 6, 5, 0, time.UTC),
+	}
+	srv, err := New(NewMemoryBackend(s.clock), Clock(s.clock))
+	c.Assert(err, IsNil)
+	s.srv = srv.(*server)
+}
+
+func (s *SessionSuite) TestID(c *C) {
 	id := NewID()
 	id2, err := ParseID(id.String())
 	c.Assert(err, IsNil)
@@ -75,7 +107,7 @@ func (s *BoltSuite) TestID(c *C) {
 	}
 }
 
-func (s *BoltSuite) TestSessionsCRUD(c *C) {
+func (s *SessionSuite) TestSessionsCRUD(c *C) {
 	out, err := s.srv.GetSessions()
 	c.Assert(err, IsNil)
 	c.Assert(len(out), Equals, 0)
@@ -125,7 +157,7 @@ func (s *BoltSuite) TestSessionsCRUD(c *C) {
 
 // TestSessionsInactivity makes sure that session will be marked
 // as inactive after period of inactivity
-func (s *BoltSuite) TestSessionsInactivity(c *C) {
+func (s *SessionSuite) TestSessionsInactivity(c *C) {
 	sess := Session{
 		ID:             NewID(),
 		Active:         true,
@@ -145,7 +177,7 @@ func (s *BoltSuite) TestSessionsInactivity(c *C) {
 	c.Assert(s2, IsNil)
 }
 
-func (s *BoltSuite) TestPartiesCRUD(c *C) {
+func (s *SessionSuite) TestPartiesCRUD(c *C) {
 	// create session:
 	sess := Session{
 		ID:             NewID(),
@@ -156,21 +188,25 @@ func (s *BoltSuite) TestPartiesCRUD(c *C) {
 		Created:        s.clock.UtcNow(),
 	}
 	c.Assert(s.srv.CreateSession(sess), IsNil)
-	// add two people:
+	// add two people: a read-only observer, and a moderator:
 	parties := []Party{
 		{
-			ID:         NewID(),
-			RemoteAddr: "1_remote_addr",
-			User:       "first",
-			ServerID:   "luna",
-			LastActive: s.clock.UtcNow(),
+			ID:          NewID(),
+			RemoteAddr:  "1_remote_addr",
+			User:        "first",
+			ServerID:    "luna",
+			LastActive:  s.clock.UtcNow(),
+			Role:        RoleObserver,
+			Permissions: DefaultPermissions(RoleObserver),
 		},
 		{
-			ID:         NewID(),
-			RemoteAddr: "2_remote_addr",
-			User:       "second",
-			ServerID:   "luna",
-			LastActive: s.clock.UtcNow(),
+			ID:          NewID(),
+			RemoteAddr:  "2_remote_addr",
+			User:        "second",
+			ServerID:    "luna",
+			LastActive:  s.clock.UtcNow(),
+			Role:        RoleModerator,
+			Permissions: DefaultPermissions(RoleModerator),
 		},
 	}
 	s.srv.UpdateSession(UpdateRequest{
@@ -182,19 +218,39 @@ func (s *BoltSuite) TestPartiesCRUD(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(len(copy.Parties), Equals, 2)
 
+	// the read-only observer cannot inject input:
+	c.Assert(s.srv.AuthorizePartyAction(sess.ID, parties[0].ID, ActionWrite), NotNil)
+	// but the moderator can:
+	c.Assert(s.srv.AuthorizePartyAction(sess.ID, parties[1].ID, ActionWrite), IsNil)
+	// and the moderator is allowed to kick other parties:
+	c.Assert(s.srv.AuthorizePartyAction(sess.ID, parties[1].ID, ActionKick), IsNil)
+
 	// empty update (list of parties must not change)
 	s.srv.UpdateSession(UpdateRequest{ID: sess.ID})
 	copy, _ = s.srv.GetSession(sess.ID)
 	c.Assert(len(copy.Parties), Equals, 2)
 
-	// remove the 2nd party:
-	deleted := copy.RemoveParty(parties[1].ID)
+	// the moderator demotes the observer to have no permissions at all:
+	copy.Parties[0].Permissions = 0
+	c.Assert(s.srv.AuthorizePartyAction(sess.ID, copy.Parties[1].ID, ActionKick), IsNil)
+	s.srv.UpdateSession(UpdateRequest{ID: copy.ID,
+		Parties: &copy.Parties})
+
+	// the demotion took effect: the observer now has no permissions at
+	// all, and still cannot write:
+	copy, _ = s.srv.GetSession(sess.ID)
+	c.Assert(copy.Parties[0].Permissions, Equals, PartyPermissions(0))
+	c.Assert(s.srv.AuthorizePartyAction(sess.ID, copy.Parties[0].ID, ActionWrite), NotNil)
+
+	// now eject them by issuing a party update:
+	deleted := copy.RemoveParty(parties[0].ID)
 	c.Assert(deleted, Equals, true)
 	s.srv.UpdateSession(UpdateRequest{ID: copy.ID,
 		Parties: &copy.Parties})
 	copy, _ = s.srv.GetSession(sess.ID)
 	c.Assert(len(copy.Parties), Equals, 1)
 
-	// we still have the 1st party in:
-	c.Assert(parties[0].ID, Equals, copy.Parties[0].ID)
+	// we still have the moderator in, and the observer is gone:
+	c.Assert(parties[1].ID, Equals, copy.Parties[0].ID)
+	c.Assert(s.srv.AuthorizePartyAction(sess.ID, parties[0].ID, ActionWrite), NotNil)
 }